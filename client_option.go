@@ -0,0 +1,43 @@
+package rerpc
+
+// callCfg holds the options that configure a client call. It's deliberately
+// unexported: most users interact with it only through CallOption.
+type callCfg struct {
+	Compressors        []string
+	UnaryInterceptors  []UnaryInterceptor
+	StreamInterceptors []StreamInterceptor
+}
+
+// A CallOption configures a client call. Like HandlerOption, CallOption is
+// implemented by a function type so that options can be composed freely.
+type CallOption interface {
+	apply(*callCfg)
+}
+
+type callOptionFunc func(*callCfg)
+
+func (f callOptionFunc) apply(cfg *callCfg) { f(cfg) }
+
+// CallCompression restricts the set of compressors a call accepts and
+// advertises, by name (for example "gzip"). Names that aren't registered
+// with the encoding package are ignored.
+//
+// By default, calls accept every compressor registered with the encoding
+// package.
+func CallCompression(names ...string) CallOption {
+	return callOptionFunc(func(cfg *callCfg) {
+		cfg.Compressors = names
+	})
+}
+
+// CallInterceptors chains UnaryInterceptors and StreamInterceptors onto a
+// call, in registration order: the first interceptor passed is outermost.
+// Later calls to CallInterceptors append to, rather than replace, any
+// interceptors already configured.
+func CallInterceptors(interceptors ...Interceptor) CallOption {
+	return callOptionFunc(func(cfg *callCfg) {
+		unary, stream := splitInterceptors(interceptors)
+		cfg.UnaryInterceptors = append(cfg.UnaryInterceptors, unary...)
+		cfg.StreamInterceptors = append(cfg.StreamInterceptors, stream...)
+	})
+}
@@ -0,0 +1,96 @@
+package rerpc
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/akshayjshah/rerpc/encoding"
+)
+
+// maybeGzipWriter is a historical name: it now dispatches to whichever
+// registered compressor the client's Accept-Encoding header prefers, rather
+// than always using gzip. cfg's Compressors allowlist is honored the same
+// way it is for gRPC, via cfg.getCompressor. If no registered and allowed
+// compressor matches, or response compression is disabled, it returns w
+// unchanged.
+//
+// The returned function must be called once the handler is done writing the
+// response, to flush and release the compressor.
+func maybeGzipWriter(w http.ResponseWriter, r *http.Request, cfg *handlerCfg) (http.ResponseWriter, func()) {
+	noop := func() {}
+	if cfg.DisableGzipResponse {
+		return w, noop
+	}
+	accept := r.Header.Get("Accept-Encoding")
+	for _, name := range strings.FieldsFunc(accept, splitOnCommasAndSpaces) {
+		if name == encoding.NameIdentity {
+			return w, noop
+		}
+		c, ok := cfg.getCompressor(name)
+		if !ok {
+			continue
+		}
+		cw, err := c.Compress(w)
+		if err != nil {
+			return w, noop
+		}
+		w.Header().Set("Content-Encoding", name)
+		return &compressedResponseWriter{ResponseWriter: w, compressor: cw}, func() { cw.Close() }
+	}
+	return w, noop
+}
+
+// maybeGzipReader is a historical name: it now dispatches to whichever
+// registered compressor the request's Content-Encoding header names. cfg's
+// Compressors allowlist is honored the same way it is for gRPC, via
+// cfg.getCompressor. If the header is unset or "identity", it returns r's
+// body unchanged; if it names a compressor absent from the registry or
+// allowlist, it returns a CodeInvalidArgument error.
+func maybeGzipReader(r *http.Request, cfg *handlerCfg) (io.Reader, func(), error) {
+	noop := func() {}
+	name := r.Header.Get("Content-Encoding")
+	if name == "" || name == encoding.NameIdentity {
+		return r.Body, noop, nil
+	}
+	c, ok := cfg.getCompressor(name)
+	if !ok {
+		return r.Body, noop, errorf(CodeInvalidArgument, "unsupported content-encoding %q", name)
+	}
+	dr, err := c.Decompress(r.Body)
+	if err != nil {
+		return r.Body, noop, err
+	}
+	if closer, ok := dr.(io.Closer); ok {
+		return dr, func() { closer.Close() }, nil
+	}
+	return dr, noop, nil
+}
+
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	compressor io.WriteCloser
+}
+
+func (w *compressedResponseWriter) Write(p []byte) (int, error) {
+	return w.compressor.Write(p)
+}
+
+// flusher is satisfied by compressors (like *gzip.Writer) that can flush
+// buffered, not-yet-compressed bytes without closing the stream.
+type flusher interface {
+	Flush() error
+}
+
+// Flush implements http.Flusher, so server-streaming handlers can still
+// flush a gzip-compressed response after every message: it flushes the
+// compressor first, so bytes it's buffered reach w.ResponseWriter, then
+// flushes the underlying connection.
+func (w *compressedResponseWriter) Flush() {
+	if f, ok := w.compressor.(flusher); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,85 @@
+package rerpc
+
+import (
+	"fmt"
+
+	"github.com/akshayjshah/rerpc/internal/statuspb/v0"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// errDetailTypes lists the google.rpc error detail messages this package
+// knows how to decode, in the same set grpc-go's status package supports.
+var errDetailTypes = []func() proto.Message{
+	func() proto.Message { return new(errdetails.RetryInfo) },
+	func() proto.Message { return new(errdetails.ErrorInfo) },
+	func() proto.Message { return new(errdetails.BadRequest) },
+	func() proto.Message { return new(errdetails.PreconditionFailure) },
+	func() proto.Message { return new(errdetails.QuotaFailure) },
+	func() proto.Message { return new(errdetails.DebugInfo) },
+	func() proto.Message { return new(errdetails.ResourceInfo) },
+	func() proto.Message { return new(errdetails.Help) },
+	func() proto.Message { return new(errdetails.LocalizedMessage) },
+}
+
+// AddDetail marshals msg into an Any using its fully-qualified type URL and
+// appends it to the error's details, mirroring grpc-go's
+// status.Status.WithDetails. Interceptors can use it, for example, to
+// attach a RetryInfo that the retry interceptor in retry.go will honor.
+func (e *Error) AddDetail(msg proto.Message) error {
+	any, err := anypb.New(msg)
+	if err != nil {
+		return fmt.Errorf("add detail %T: %w", msg, err)
+	}
+	e.details = append(e.details, any)
+	return nil
+}
+
+// Detail unmarshals the first detail whose type matches out into out,
+// reporting whether a match was found and decoded. Use it when you only care about one
+// specific detail message; use Details to decode all of them.
+func (e *Error) Detail(out proto.Message) bool {
+	for _, any := range e.details {
+		if any.MessageIs(out) {
+			return any.UnmarshalTo(out) == nil
+		}
+	}
+	return false
+}
+
+// Details decodes every detail of a type this package recognizes - the
+// google.rpc error detail messages in the errdetails package - skipping any
+// detail of an unrecognized type.
+func (e *Error) Details() []proto.Message {
+	var out []proto.Message
+	for _, any := range e.details {
+		for _, newDetail := range errDetailTypes {
+			msg := newDetail()
+			if !any.MessageIs(msg) {
+				continue
+			}
+			if any.UnmarshalTo(msg) == nil {
+				out = append(out, msg)
+			}
+			break
+		}
+	}
+	return out
+}
+
+// rawDetails returns the error's details as wire-format Any messages, for
+// embedding directly into a google.rpc.Status.
+func (e *Error) rawDetails() []*anypb.Any {
+	return e.details
+}
+
+// errorFromStatus rebuilds an *Error from a decoded google.rpc.Status,
+// preserving its details so they can be inspected with Detail and Details.
+// No code in this package calls it: a Grpc-Status-Details-Bin trailer isn't
+// decoded into an error through it.
+func errorFromStatus(s *statuspb.Status) *Error {
+	e := errorf(Code(s.Code), "%s", s.Message)
+	e.details = s.Details
+	return e
+}
@@ -0,0 +1,63 @@
+// Package encoding provides a pluggable registry of message compressors
+// shared by reRPC's handlers and clients. reRPC registers gzip and identity
+// implementations by default; callers can register additional codecs (for
+// example snappy or zstd) from an init function before constructing any
+// Handler or client.
+package encoding
+
+import (
+	"io"
+	"sync"
+)
+
+// A Compressor knows how to compress and decompress message bodies for a
+// single content-coding, as named in the Grpc-Encoding and
+// Grpc-Accept-Encoding headers (for example "gzip"). Implementations must be
+// safe for concurrent use.
+type Compressor interface {
+	// Name returns the wire name of the content-coding.
+	Name() string
+	// Compress returns a WriteCloser that compresses data written to it and
+	// forwards the compressed bytes to w. Callers must Close the returned
+	// writer to flush any buffered data.
+	Compress(w io.Writer) (io.WriteCloser, error)
+	// Decompress returns a Reader that yields the decompressed bytes of r.
+	Decompress(r io.Reader) (io.Reader, error)
+}
+
+var (
+	mu          sync.RWMutex
+	compressors = make(map[string]Compressor)
+	names       []string
+)
+
+// RegisterCompressor registers a Compressor under its Name(), replacing any
+// previously-registered Compressor with the same name. It's typically called
+// from an init function, before any Handler or client is constructed.
+func RegisterCompressor(c Compressor) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := c.Name()
+	if _, ok := compressors[name]; !ok {
+		names = append(names, name)
+	}
+	compressors[name] = c
+}
+
+// GetCompressor returns the Compressor registered under name, if any.
+func GetCompressor(name string) (Compressor, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := compressors[name]
+	return c, ok
+}
+
+// Names returns the wire names of every registered Compressor, in
+// registration order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, len(names))
+	copy(out, names)
+	return out
+}
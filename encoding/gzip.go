@@ -0,0 +1,27 @@
+package encoding
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+func init() {
+	RegisterCompressor(&gzipCompressor{})
+}
+
+// NameGzip is the wire name of the built-in gzip compressor.
+const NameGzip = "gzip"
+
+// gzipCompressor is reRPC's built-in gzip Compressor. It's registered by
+// default, matching the grpc-go and grpc-java reference implementations.
+type gzipCompressor struct{}
+
+func (*gzipCompressor) Name() string { return NameGzip }
+
+func (*gzipCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (*gzipCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
@@ -0,0 +1,30 @@
+package encoding
+
+import "io"
+
+func init() {
+	RegisterCompressor(&identityCompressor{})
+}
+
+// NameIdentity is the wire name of the no-op compressor.
+const NameIdentity = "identity"
+
+// identityCompressor is a no-op Compressor, registered by default so that
+// callers can always look up an encoder for the "identity" coding.
+type identityCompressor struct{}
+
+func (*identityCompressor) Name() string { return NameIdentity }
+
+func (*identityCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (*identityCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return r, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
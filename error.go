@@ -0,0 +1,169 @@
+package rerpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Code is a gRPC status code, as defined at
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md.
+type Code int32
+
+const (
+	CodeOK Code = iota
+	CodeCanceled
+	CodeUnknown
+	CodeInvalidArgument
+	CodeDeadlineExceeded
+	CodeNotFound
+	CodeAlreadyExists
+	CodePermissionDenied
+	CodeResourceExhausted
+	CodeFailedPrecondition
+	CodeAborted
+	CodeOutOfRange
+	CodeUnimplemented
+	CodeInternal
+	CodeUnavailable
+	CodeDataLoss
+	CodeUnauthenticated
+)
+
+// String implements fmt.Stringer.
+func (c Code) String() string {
+	switch c {
+	case CodeOK:
+		return "ok"
+	case CodeCanceled:
+		return "canceled"
+	case CodeUnknown:
+		return "unknown"
+	case CodeInvalidArgument:
+		return "invalid_argument"
+	case CodeDeadlineExceeded:
+		return "deadline_exceeded"
+	case CodeNotFound:
+		return "not_found"
+	case CodeAlreadyExists:
+		return "already_exists"
+	case CodePermissionDenied:
+		return "permission_denied"
+	case CodeResourceExhausted:
+		return "resource_exhausted"
+	case CodeFailedPrecondition:
+		return "failed_precondition"
+	case CodeAborted:
+		return "aborted"
+	case CodeOutOfRange:
+		return "out_of_range"
+	case CodeUnimplemented:
+		return "unimplemented"
+	case CodeInternal:
+		return "internal"
+	case CodeUnavailable:
+		return "unavailable"
+	case CodeDataLoss:
+		return "data_loss"
+	case CodeUnauthenticated:
+		return "unauthenticated"
+	default:
+		return fmt.Sprintf("code_%d", int32(c))
+	}
+}
+
+// http maps c to the HTTP status reRPC's JSON protocol sends, following the
+// same table as grpc-gateway.
+func (c Code) http() int {
+	switch c {
+	case CodeOK:
+		return http.StatusOK
+	case CodeCanceled:
+		return 499 // Client Closed Request
+	case CodeUnknown, CodeInternal, CodeDataLoss:
+		return http.StatusInternalServerError
+	case CodeInvalidArgument, CodeFailedPrecondition, CodeOutOfRange:
+		return http.StatusBadRequest
+	case CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeAlreadyExists, CodeAborted:
+		return http.StatusConflict
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case CodeUnimplemented:
+		return http.StatusNotImplemented
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// An Error captures a status Code, a message, and optional structured
+// details, mirroring google.rpc.Status. It's the error type reRPC writes to
+// the wire on both the JSON and gRPC protocols.
+type Error struct {
+	code    Code
+	err     error
+	details []*anypb.Any
+	header  http.Header
+}
+
+// errorf builds an Error from a format string, the same way fmt.Errorf
+// builds a plain error.
+func errorf(c Code, format string, a ...interface{}) *Error {
+	return &Error{code: c, err: fmt.Errorf(format, a...)}
+}
+
+// wrap annotates err with a status code. It returns nil if err is nil, so
+// callers can use it unconditionally on a function's returned error.
+func wrap(c Code, err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{code: c, err: err}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.code.String() + ": " + e.err.Error()
+}
+
+// Unwrap allows unwrapping errors.Is and errors.As checks through to the
+// underlying error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Code returns the error's status code.
+func (e *Error) Code() Code {
+	return e.code
+}
+
+// Header returns the headers carried alongside this error, lazily allocating
+// it if necessary. Interceptors can use it to attach metadata like
+// Retry-After, which the retry interceptor reads. The handler's error writers
+// don't send these headers on the wire, and received response headers aren't
+// copied into it.
+func (e *Error) Header() http.Header {
+	if e.header == nil {
+		e.header = make(http.Header)
+	}
+	return e.header
+}
+
+// AsError uses errors.As to unwrap err, returning the first *Error in its
+// chain.
+func AsError(err error) (*Error, bool) {
+	var re *Error
+	ok := errors.As(err, &re)
+	return re, ok
+}
@@ -0,0 +1,222 @@
+package rerpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/akshayjshah/rerpc/encoding"
+)
+
+// gRPC-Web content types. Unlike application/grpc, gRPC-Web is designed to
+// be usable from a browser's fetch/XHR implementation, which can't read HTTP
+// trailers: status is instead carried in a trailer frame at the end of the
+// response body. See
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md.
+const (
+	TypeGRPCWeb          = "application/grpc-web"
+	TypeGRPCWebProto     = "application/grpc-web+proto"
+	TypeGRPCWebText      = "application/grpc-web-text"
+	TypeGRPCWebTextProto = "application/grpc-web-text+proto"
+)
+
+// grpcWebTrailerFlag marks an LPM envelope as a trailer frame (carrying
+// status, rather than a message) per the gRPC-Web wire protocol.
+const grpcWebTrailerFlag = 0b10000000
+
+// isGRPCWebContentType does not recognize the "+json" gRPC-Web variants
+// (application/grpc-web+json, application/grpc-web-text+json). Unlike the
+// protobuf variants, a JSON-framed gRPC-Web message needs its own LPM codec
+// path (message bytes would be protojson, not the wire format marshalLPM
+// and unmarshalLPM assume), parallel to how serveJSON has its own
+// ndjsonFrameWriter rather than reusing lpmFrameWriter. That codec doesn't
+// exist yet, so "+json" requests correctly fall through to the 415 branch
+// in Serve rather than being misparsed as protobuf.
+func isGRPCWebContentType(ctype string) bool {
+	switch ctype {
+	case TypeGRPCWeb, TypeGRPCWebProto, TypeGRPCWebText, TypeGRPCWebTextProto:
+		return true
+	default:
+		return false
+	}
+}
+
+func isGRPCWebTextContentType(ctype string) bool {
+	return ctype == TypeGRPCWebText || ctype == TypeGRPCWebTextProto
+}
+
+func (h *Handler) serveGRPCWeb(w http.ResponseWriter, r *http.Request, msg proto.Message, ctype string) {
+	isText := isGRPCWebTextContentType(ctype)
+
+	acceptEncodingValue := h.config.acceptEncodingValue()
+	w.Header().Set("Grpc-Accept-Encoding", acceptEncodingValue)
+	w.Header().Set("User-Agent", UserAgent)
+	// Browsers refuse to expose response headers to client JS unless the
+	// server explicitly allows it.
+	w.Header().Set("Access-Control-Expose-Headers", "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin")
+
+	requestCompression := encoding.NameIdentity
+	if me := r.Header.Get("Grpc-Encoding"); me != "" && me != encoding.NameIdentity {
+		if _, ok := h.config.getCompressor(me); !ok {
+			h.finishGRPCWeb(w, isText, errorf(CodeUnimplemented, "unknown compression %q: accepted grpc-encoding values are %v", me, acceptEncodingValue))
+			return
+		}
+		requestCompression = me
+	}
+	responseCompression := requestCompression
+	if mae := r.Header.Get("Grpc-Accept-Encoding"); mae != "" {
+		for _, enc := range strings.FieldsFunc(mae, splitOnCommasAndSpaces) {
+			if _, ok := h.config.getCompressor(enc); ok {
+				responseCompression = enc
+				break
+			}
+		}
+	}
+	if h.config.DisableGzipResponse {
+		responseCompression = encoding.NameIdentity
+	}
+	w.Header().Set("Grpc-Encoding", responseCompression)
+
+	r, cancel, err := applyTimeout(r, h.config.MinTimeout, h.config.MaxTimeout)
+	if err != nil {
+		// Errors here indicate that the client sent an invalid timeout header, so
+		// the exact error is safe to send back.
+		h.finishGRPCWeb(w, isText, wrap(CodeInvalidArgument, err))
+		return
+	}
+	defer cancel()
+
+	if h.streamType == streamTypeClient || h.streamType == streamTypeBidi {
+		// gRPC-Web has no browser-usable transport for a client that keeps
+		// sending after the initial request, so only unary and server-streaming
+		// RPCs are served over this protocol.
+		h.finishGRPCWeb(w, isText, errorf(CodeUnimplemented, "client- and bidi-streaming RPCs aren't available over gRPC-Web"))
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if isText {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if err := unmarshalLPM(body, msg, requestCompression, h.config.MaxRequestBytes); err != nil {
+		// TODO: observability
+		h.finishGRPCWeb(w, isText, errorf(CodeInvalidArgument, "can't unmarshal protobuf request"))
+		return
+	}
+
+	bw := io.Writer(w)
+	var textEncoder io.WriteCloser
+	if isText {
+		// application/grpc-web-text base64-encodes the whole stream, not each
+		// frame individually, so the message and trailer frames below share one
+		// encoder.
+		textEncoder = base64.NewEncoder(base64.StdEncoding, w)
+		bw = textEncoder
+	}
+	finish := func(err error) {
+		h.writeTrailerGRPCWeb(bw, err)
+		if textEncoder != nil {
+			textEncoder.Close()
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	info := h.callMeta(r, ProtocolGRPCWeb)
+
+	if h.streamType == streamTypeServer {
+		flusher, _ := w.(http.Flusher)
+		stream := newServerStream(&lpmFrameWriter{bw, responseCompression}, flusher)
+		core := func(ctx context.Context) error {
+			return h.serverStreamImplementation(ctx, msg, stream)
+		}
+		if err := runStream(r.Context(), info, h.config.StreamInterceptors, core); err != nil {
+			finish(err)
+			return
+		}
+		finish(nil)
+		return
+	}
+
+	res, implErr := h.unaryChain(r.Context(), msg, info)
+	if implErr != nil {
+		// It's the user's job to sanitize the error string.
+		finish(implErr)
+		return
+	}
+
+	if err := marshalLPM(bw, res, responseCompression, 0 /* maxBytes */); err != nil {
+		// It's safe to write the trailer frame even after we've started writing
+		// the body.
+		// TODO: observability
+		finish(errorf(CodeUnknown, "can't marshal protobuf response"))
+		return
+	}
+
+	finish(nil)
+}
+
+// finishGRPCWeb writes only the trailer frame: used when the handler never
+// got far enough to write a message frame.
+func (h *Handler) finishGRPCWeb(w http.ResponseWriter, isText bool, err error) {
+	bw := io.Writer(w)
+	var textEncoder io.WriteCloser
+	if isText {
+		textEncoder = base64.NewEncoder(base64.StdEncoding, w)
+		bw = textEncoder
+	}
+	h.writeTrailerGRPCWeb(bw, err)
+	if textEncoder != nil {
+		textEncoder.Close()
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeTrailerGRPCWeb writes the gRPC status as a trailer frame: an LPM
+// envelope whose compression byte has grpcWebTrailerFlag set, and whose
+// payload is an HTTP/1.1-style header block.
+func (h *Handler) writeTrailerGRPCWeb(w io.Writer, err error) {
+	code := CodeOK
+	message := ""
+	detailsBin := ""
+	if err != nil {
+		s := statusFromError(err)
+		code = Code(s.Code)
+		message = s.Message
+		if bin, merr := proto.Marshal(s); merr == nil {
+			detailsBin = encodeBinaryHeader(bin)
+		}
+	}
+
+	var headers bytes.Buffer
+	headers.WriteString("grpc-status: ")
+	headers.WriteString(strconv.Itoa(int(code)))
+	headers.WriteString("\r\n")
+	if message != "" {
+		headers.WriteString("grpc-message: ")
+		headers.WriteString(percentEncode(message))
+		headers.WriteString("\r\n")
+	}
+	if detailsBin != "" {
+		headers.WriteString("grpc-status-details-bin: ")
+		headers.WriteString(detailsBin)
+		headers.WriteString("\r\n")
+	}
+
+	envelope := make([]byte, 5+headers.Len())
+	envelope[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(envelope[1:5], uint32(headers.Len()))
+	copy(envelope[5:], headers.Bytes())
+	w.Write(envelope) // nolint: errcheck // no way to signal a write error this late
+}
@@ -12,12 +12,11 @@ import (
 
 	"google.golang.org/protobuf/proto"
 
+	"github.com/akshayjshah/rerpc/encoding"
 	"github.com/akshayjshah/rerpc/internal/statuspb/v0"
 )
 
 var (
-	// Always advertise that reRPC accepts gzip compression.
-	acceptEncodingValue    = strings.Join([]string{CompressionGzip, CompressionIdentity}, ",")
 	acceptPostValueDefault = strings.Join(
 		[]string{TypeDefaultGRPC, TypeProtoGRPC, TypeJSON},
 		",",
@@ -35,6 +34,57 @@ type handlerCfg struct {
 	DisableJSON         bool
 	MaxRequestBytes     int
 	Registrar           *Registrar
+	// Compressors restricts which registered encoding.Compressor names this
+	// handler accepts and advertises. A nil slice means every compressor
+	// registered with the encoding package is allowed.
+	Compressors        []string
+	UnaryInterceptors  []UnaryInterceptor
+	StreamInterceptors []StreamInterceptor
+}
+
+// acceptEncodingValue returns the value to advertise in the
+// Grpc-Accept-Encoding header: the names of every compressor this handler
+// will accept, in registration order.
+func (cfg *handlerCfg) acceptEncodingValue() string {
+	return strings.Join(cfg.compressorNames(), ",")
+}
+
+// compressorNames returns the registered compressor names this handler
+// accepts, respecting the Compressors allowlist.
+func (cfg *handlerCfg) compressorNames() []string {
+	all := encoding.Names()
+	if len(cfg.Compressors) == 0 {
+		return all
+	}
+	allowed := make(map[string]bool, len(cfg.Compressors))
+	for _, name := range cfg.Compressors {
+		allowed[name] = true
+	}
+	names := make([]string, 0, len(all))
+	for _, name := range all {
+		if allowed[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// getCompressor looks up the named compressor, respecting the Compressors
+// allowlist.
+func (cfg *handlerCfg) getCompressor(name string) (encoding.Compressor, bool) {
+	if len(cfg.Compressors) > 0 {
+		allowed := false
+		for _, n := range cfg.Compressors {
+			if n == name {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, false
+		}
+	}
+	return encoding.GetCompressor(name)
 }
 
 type HandlerOption interface {
@@ -99,6 +149,30 @@ func HandlerSupportJSON(enable bool) HandlerOption {
 	})
 }
 
+// HandlerCompression restricts the set of compressors a handler accepts and
+// advertises, by name (for example "gzip"). Names that aren't registered
+// with the encoding package are ignored.
+//
+// By default, handlers accept every compressor registered with the encoding
+// package.
+func HandlerCompression(names ...string) HandlerOption {
+	return handlerOptionFunc(func(cfg *handlerCfg) {
+		cfg.Compressors = names
+	})
+}
+
+// HandlerInterceptors chains UnaryInterceptors and StreamInterceptors onto a
+// Handler, in registration order: the first interceptor passed is
+// outermost. Later calls to HandlerInterceptors append to, rather than
+// replace, any interceptors already configured.
+func HandlerInterceptors(interceptors ...Interceptor) HandlerOption {
+	return handlerOptionFunc(func(cfg *handlerCfg) {
+		unary, stream := splitInterceptors(interceptors)
+		cfg.UnaryInterceptors = append(cfg.UnaryInterceptors, unary...)
+		cfg.StreamInterceptors = append(cfg.StreamInterceptors, stream...)
+	})
+}
+
 // A Handler is the server-side implementation of a single RPC defined by a
 // protocol buffer service. It's the interface between the reRPC library and
 // the code generated by the reRPC protoc plugin; most users won't ever need to
@@ -107,7 +181,13 @@ func HandlerSupportJSON(enable bool) HandlerOption {
 // To see an example of how Handler is used in the generated code, see the
 // internal/pingpb/v0 package.
 type Handler struct {
-	implementation func(context.Context, proto.Message) (proto.Message, error)
+	fqn                        string // fully-qualified protobuf method name
+	implementation             func(context.Context, proto.Message) (proto.Message, error)
+	unaryChain                 func(context.Context, proto.Message, *CallMeta) (proto.Message, error)
+	serverStreamImplementation func(context.Context, proto.Message, *ServerStream) error
+	clientStreamImplementation func(context.Context, *ClientStream) (proto.Message, error)
+	bidiStreamImplementation   func(context.Context, *ClientStream, *ServerStream) error
+	streamType                 streamType
 	// rawGRPC is used only for our hand-rolled reflection handler, which needs
 	// bidi streaming
 	rawGRPC func(
@@ -119,25 +199,32 @@ type Handler struct {
 	config handlerCfg
 }
 
-// NewHandler constructs a Handler.
+// NewHandler constructs a Handler for a unary RPC. See NewServerStreamHandler,
+// NewClientStreamHandler, and NewBidiStreamHandler for streaming RPCs.
 func NewHandler(
 	fqn string, // fully-qualified protobuf method name
 	impl func(context.Context, proto.Message) (proto.Message, error),
 	opts ...HandlerOption,
 ) *Handler {
-	var cfg handlerCfg
-	for _, opt := range opts {
-		opt.apply(&cfg)
-	}
-	if reg := cfg.Registrar; reg != nil {
-		reg.register(fqn)
-	}
+	cfg := newHandlerCfg(fqn, opts)
 	return &Handler{
+		fqn:            fqn,
 		implementation: impl,
+		unaryChain:     chainUnary(impl, cfg.UnaryInterceptors),
 		config:         cfg,
 	}
 }
 
+// callMeta builds the CallMeta passed to interceptors for a single request.
+func (h *Handler) callMeta(r *http.Request, protocol Protocol) *CallMeta {
+	return &CallMeta{
+		Method:   h.fqn,
+		Protocol: protocol,
+		Peer:     r.RemoteAddr,
+		Header:   r.Header,
+	}
+}
+
 // Serve executes the handler, much like the standard library's http.Handler.
 // Unlike http.Handler, it requires a pointer to the protoc-generated request
 // struct. See the internal/pingpb/v0 package for an example of how this code
@@ -165,7 +252,8 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, msg proto.Messag
 		w.WriteHeader(http.StatusUnsupportedMediaType)
 		return
 	}
-	if ctype != TypeDefaultGRPC && ctype != TypeProtoGRPC && ctype != TypeJSON {
+	isGRPCWeb := isGRPCWebContentType(ctype)
+	if ctype != TypeDefaultGRPC && ctype != TypeProtoGRPC && ctype != TypeJSON && !isGRPCWeb {
 		// grpc-go returns 500, but the spec recommends 415.
 		// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md#requests
 		w.Header().Set("Accept-Post", acceptPostValueDefault)
@@ -175,7 +263,9 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, msg proto.Messag
 
 	// We're always going to respond with the same content type as the request.
 	w.Header().Set("Content-Type", ctype)
-	if ctype == TypeJSON {
+	if isGRPCWeb {
+		h.serveGRPCWeb(w, r, msg, ctype)
+	} else if ctype == TypeJSON {
 		h.serveJSON(w, r, msg)
 	} else {
 		h.serveGRPC(w, r, msg)
@@ -185,7 +275,7 @@ func (h *Handler) Serve(w http.ResponseWriter, r *http.Request, msg proto.Messag
 func (h *Handler) serveJSON(w http.ResponseWriter, r *http.Request, msg proto.Message) {
 	if !h.config.DisableGzipResponse {
 		var returnToPool func()
-		w, returnToPool = maybeGzipWriter(w, r)
+		w, returnToPool = maybeGzipWriter(w, r, &h.config)
 		defer returnToPool()
 	}
 
@@ -198,7 +288,7 @@ func (h *Handler) serveJSON(w http.ResponseWriter, r *http.Request, msg proto.Me
 	}
 	defer cancel()
 
-	body, closeReader, err := maybeGzipReader(r)
+	body, closeReader, err := maybeGzipReader(r, &h.config)
 	if err != nil {
 		// TODO: observability
 		writeErrorJSON(w, errorf(CodeUnknown, "can't read gzipped body"))
@@ -219,7 +309,30 @@ func (h *Handler) serveJSON(w http.ResponseWriter, r *http.Request, msg proto.Me
 		return
 	}
 
-	res, implErr := h.implementation(r.Context(), msg)
+	if h.streamType == streamTypeClient || h.streamType == streamTypeBidi {
+		// Client- and bidi-streaming need more than one request message, which
+		// the JSON protocol has no framing for. Only application/grpc serves
+		// these handlers.
+		writeErrorJSON(w, errorf(CodeUnimplemented, "client- and bidi-streaming RPCs aren't available over JSON"))
+		return
+	}
+
+	info := h.callMeta(r, ProtocolJSON)
+
+	if h.streamType == streamTypeServer {
+		flusher, _ := w.(http.Flusher)
+		stream := newServerStream(&ndjsonFrameWriter{w}, flusher)
+		core := func(ctx context.Context) error {
+			return h.serverStreamImplementation(ctx, msg, stream)
+		}
+		if err := runStream(r.Context(), info, h.config.StreamInterceptors, core); err != nil {
+			// It's the user's job to sanitize the error string.
+			writeErrorJSON(w, err)
+		}
+		return
+	}
+
+	res, implErr := h.unaryChain(r.Context(), msg, info)
 	if implErr != nil {
 		// It's the user's job to sanitize the error string.
 		writeErrorJSON(w, implErr)
@@ -235,6 +348,7 @@ func (h *Handler) serveJSON(w http.ResponseWriter, r *http.Request, msg proto.Me
 func (h *Handler) serveGRPC(w http.ResponseWriter, r *http.Request, msg proto.Message) {
 	// We always send grpc-accept-encoding. Set it here so it's ready to go in
 	// future error cases.
+	acceptEncodingValue := h.config.acceptEncodingValue()
 	w.Header().Set("Grpc-Accept-Encoding", acceptEncodingValue)
 	w.Header().Set("User-Agent", UserAgent)
 	// Every gRPC response will have these trailers.
@@ -242,20 +356,16 @@ func (h *Handler) serveGRPC(w http.ResponseWriter, r *http.Request, msg proto.Me
 	w.Header().Add("Trailer", "Grpc-Message")
 	w.Header().Add("Trailer", "Grpc-Status-Details-Bin")
 
-	requestCompression := CompressionIdentity
-	if me := r.Header.Get("Grpc-Encoding"); me != "" {
-		switch me {
-		case CompressionIdentity:
-			requestCompression = CompressionIdentity
-		case CompressionGzip:
-			requestCompression = CompressionGzip
-		default:
+	requestCompression := encoding.NameIdentity
+	if me := r.Header.Get("Grpc-Encoding"); me != "" && me != encoding.NameIdentity {
+		if _, ok := h.config.getCompressor(me); !ok {
 			// Per https://github.com/grpc/grpc/blob/master/doc/compression.md, we
 			// should return CodeUnimplemented and specify acceptable compression(s)
 			// (in addition to setting the Grpc-Accept-Encoding header).
 			writeErrorGRPC(w, errorf(CodeUnimplemented, "unknown compression %q: accepted grpc-encoding values are %v", me, acceptEncodingValue))
 			return
 		}
+		requestCompression = me
 	}
 
 	// Follow https://github.com/grpc/grpc/blob/master/doc/compression.md.
@@ -264,18 +374,14 @@ func (h *Handler) serveGRPC(w http.ResponseWriter, r *http.Request, msg proto.Me
 	responseCompression := requestCompression
 	if mae := r.Header.Get("Grpc-Accept-Encoding"); mae != "" {
 		for _, enc := range strings.FieldsFunc(mae, splitOnCommasAndSpaces) {
-			switch enc {
-			case CompressionGzip: // prefer gzip
-				responseCompression = CompressionGzip
-				break
-			case CompressionIdentity:
-				responseCompression = CompressionIdentity
+			if _, ok := h.config.getCompressor(enc); ok {
+				responseCompression = enc
 				break
 			}
 		}
 	}
 	if h.config.DisableGzipResponse {
-		responseCompression = CompressionIdentity
+		responseCompression = encoding.NameIdentity
 	}
 	w.Header().Set("Grpc-Encoding", responseCompression)
 
@@ -293,13 +399,67 @@ func (h *Handler) serveGRPC(w http.ResponseWriter, r *http.Request, msg proto.Me
 		return
 	}
 
+	flusher, _ := w.(http.Flusher)
+	info := h.callMeta(r, ProtocolGRPC)
+
+	switch h.streamType {
+	case streamTypeServer:
+		if err := unmarshalLPM(r.Body, msg, requestCompression, h.config.MaxRequestBytes); err != nil {
+			// TODO: observability
+			writeErrorGRPC(w, errorf(CodeInvalidArgument, "can't unmarshal protobuf request"))
+			return
+		}
+		stream := newServerStream(&lpmFrameWriter{w, responseCompression}, flusher)
+		core := func(ctx context.Context) error {
+			return h.serverStreamImplementation(ctx, msg, stream)
+		}
+		if err := runStream(r.Context(), info, h.config.StreamInterceptors, core); err != nil {
+			// It's the user's job to sanitize the error string.
+			writeErrorGRPC(w, err)
+			return
+		}
+		writeErrorGRPC(w, nil)
+		return
+	case streamTypeClient:
+		stream := newClientStream(r.Body, requestCompression, h.config.MaxRequestBytes)
+		var res proto.Message
+		core := func(ctx context.Context) error {
+			var implErr error
+			res, implErr = h.clientStreamImplementation(ctx, stream)
+			return implErr
+		}
+		if err := runStream(r.Context(), info, h.config.StreamInterceptors, core); err != nil {
+			writeErrorGRPC(w, err)
+			return
+		}
+		if err := marshalLPM(w, res, responseCompression, 0 /* maxBytes */); err != nil {
+			// TODO: observability
+			writeErrorGRPC(w, errorf(CodeUnknown, "can't marshal protobuf response"))
+			return
+		}
+		writeErrorGRPC(w, nil)
+		return
+	case streamTypeBidi:
+		cstream := newClientStream(r.Body, requestCompression, h.config.MaxRequestBytes)
+		sstream := newServerStream(&lpmFrameWriter{w, responseCompression}, flusher)
+		core := func(ctx context.Context) error {
+			return h.bidiStreamImplementation(ctx, cstream, sstream)
+		}
+		if err := runStream(r.Context(), info, h.config.StreamInterceptors, core); err != nil {
+			writeErrorGRPC(w, err)
+			return
+		}
+		writeErrorGRPC(w, nil)
+		return
+	}
+
 	if err := unmarshalLPM(r.Body, msg, requestCompression, h.config.MaxRequestBytes); err != nil {
 		// TODO: observability
 		writeErrorGRPC(w, errorf(CodeInvalidArgument, "can't unmarshal protobuf request"))
 		return
 	}
 
-	res, implErr := h.implementation(r.Context(), msg)
+	res, implErr := h.unaryChain(r.Context(), msg, info)
 	if implErr != nil {
 		// It's the user's job to sanitize the error string.
 		writeErrorGRPC(w, implErr)
@@ -368,7 +528,7 @@ func statusFromError(err error) *statuspb.Status {
 	}
 	if re, ok := AsError(err); ok {
 		s.Code = int32(re.Code())
-		s.Details = re.Details()
+		s.Details = re.rawDetails()
 		if e := re.Unwrap(); e != nil {
 			s.Message = e.Error() // don't repeat code
 		}
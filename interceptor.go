@@ -0,0 +1,118 @@
+package rerpc
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Protocol identifies which wire protocol is serving a call, so interceptors
+// can make protocol-aware decisions.
+type Protocol int
+
+const (
+	ProtocolGRPC Protocol = iota
+	ProtocolGRPCWeb
+	ProtocolJSON
+)
+
+// String implements fmt.Stringer.
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolGRPC:
+		return "grpc"
+	case ProtocolGRPCWeb:
+		return "grpc-web"
+	case ProtocolJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// CallMeta describes the call an interceptor is wrapping. Method is fixed
+// for the lifetime of the Handler or client; Protocol, Peer, and Header
+// reflect the specific request being served.
+type CallMeta struct {
+	Method   string // fully-qualified protobuf method name
+	Protocol Protocol
+	Peer     string
+	Header   http.Header
+}
+
+// UnaryHandlerFunc is the signature of a unary RPC implementation: the same
+// shape NewHandler accepts.
+type UnaryHandlerFunc func(context.Context, proto.Message) (proto.Message, error)
+
+// UnaryInterceptor wraps a unary RPC invocation, forwarding to (or replacing)
+// next. It's the building block for cross-cutting concerns like auth,
+// logging, metrics, recovery, and rate-limiting.
+type UnaryInterceptor func(ctx context.Context, req proto.Message, info *CallMeta, next UnaryHandlerFunc) (proto.Message, error)
+
+// StreamHandlerFunc is the signature of a streaming RPC invocation, already
+// bound to its request and response streams.
+type StreamHandlerFunc func(ctx context.Context) error
+
+// StreamInterceptor wraps a streaming RPC invocation (server-, client-, or
+// bidi-streaming), forwarding to (or replacing) next.
+type StreamInterceptor func(ctx context.Context, info *CallMeta, next StreamHandlerFunc) error
+
+// Interceptor is implemented by UnaryInterceptor and StreamInterceptor, so
+// HandlerInterceptors and CallInterceptors can chain both kinds together in
+// a single, registration-ordered list.
+type Interceptor interface {
+	isInterceptor()
+}
+
+func (UnaryInterceptor) isInterceptor()  {}
+func (StreamInterceptor) isInterceptor() {}
+
+// splitInterceptors separates a mixed Interceptor list into its unary and
+// streaming components, preserving registration order within each.
+func splitInterceptors(interceptors []Interceptor) ([]UnaryInterceptor, []StreamInterceptor) {
+	var unary []UnaryInterceptor
+	var stream []StreamInterceptor
+	for _, i := range interceptors {
+		switch ic := i.(type) {
+		case UnaryInterceptor:
+			unary = append(unary, ic)
+		case StreamInterceptor:
+			stream = append(stream, ic)
+		}
+	}
+	return unary, stream
+}
+
+// chainUnary composes impl with interceptors, in registration order: the
+// first interceptor registered is outermost. The resulting function is built
+// once, at Handler construction, and invoked with each call's CallMeta.
+func chainUnary(impl UnaryHandlerFunc, interceptors []UnaryInterceptor) func(context.Context, proto.Message, *CallMeta) (proto.Message, error) {
+	chained := func(ctx context.Context, req proto.Message, info *CallMeta) (proto.Message, error) {
+		return impl(ctx, req)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], chained
+		chained = func(ctx context.Context, req proto.Message, info *CallMeta) (proto.Message, error) {
+			return interceptor(ctx, req, info, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return next(ctx, req, info)
+			})
+		}
+	}
+	return chained
+}
+
+// runStream invokes core through interceptors, in registration order: the
+// first interceptor registered is outermost. Unlike chainUnary, the chain is
+// assembled per call, since core is already bound to that call's request and
+// response streams.
+func runStream(ctx context.Context, info *CallMeta, interceptors []StreamInterceptor, core StreamHandlerFunc) error {
+	chained := core
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], chained
+		chained = func(ctx context.Context) error {
+			return interceptor(ctx, info, next)
+		}
+	}
+	return chained(ctx)
+}
@@ -0,0 +1,181 @@
+package rerpc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultRetryableCodes are the status codes WithRetryPolicy treats as
+// transient when the caller doesn't supply its own list. CodeInternal is
+// deliberately absent: it's only safe to retry when the caller knows their
+// server only returns it before reading the request body, so callers that
+// want it must say so explicitly via RetryableCodes.
+var defaultRetryableCodes = []Code{
+	CodeUnavailable,
+	CodeResourceExhausted,
+	CodeAborted,
+	CodeDeadlineExceeded,
+}
+
+const (
+	defaultBaseDelay  = 100 * time.Millisecond
+	defaultMaxDelay   = 5 * time.Second
+	defaultMaxAttempt = 1
+)
+
+// WithRetryPolicy retries failed unary calls, following the pRPC pattern: a
+// status code is classified as permanent or transient, and transient
+// failures are replayed up to maxAttempts times total (so 1 means no
+// retries) with exponential backoff plus full jitter:
+//
+//	sleep = rand(0, min(maxDelay, baseDelay*2^attempt))
+//
+// A Retry-After value in the error's Header, or else the retry delay of a
+// RetryInfo detail on the error, overrides the computed delay for that
+// attempt. Retries stop early once the context's deadline would elapse
+// before the delay does, since there's no point sleeping past the point
+// the caller has already given up.
+//
+// maxAttempts, baseDelay, and maxDelay fall back to 1, 100ms, and 5s
+// respectively when zero or negative. retryableCodes falls back to
+// Unavailable, ResourceExhausted, Aborted, and DeadlineExceeded.
+//
+// WithRetryPolicy only retries unary calls: a unary request message is
+// already held in memory, so replaying it is free. Streaming calls aren't
+// retried, because by the time a StreamInterceptor's next fails, it's
+// already consumed the outbound LPM stream; retrying it without buffering
+// and replaying those bytes would resend an empty or partial request.
+// Register a StreamInterceptor that buffers and replays the outbound
+// stream, via CallInterceptors, if you need streaming retries.
+//
+// Like any other CallOption built on Interceptor, WithRetryPolicy composes
+// with CallInterceptors: register a custom UnaryInterceptor alongside it to
+// layer in auth, logging, or a different retry predicate entirely.
+func WithRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration, retryableCodes ...Code) CallOption {
+	p := &retryPolicy{
+		maxAttempts:    maxAttempts,
+		baseDelay:      baseDelay,
+		maxDelay:       maxDelay,
+		retryableCodes: retryableCodes,
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = defaultMaxAttempt
+	}
+	if p.baseDelay <= 0 {
+		p.baseDelay = defaultBaseDelay
+	}
+	if p.maxDelay <= 0 {
+		p.maxDelay = defaultMaxDelay
+	}
+	if len(p.retryableCodes) == 0 {
+		p.retryableCodes = defaultRetryableCodes
+	}
+	return callOptionFunc(func(cfg *callCfg) {
+		cfg.UnaryInterceptors = append(cfg.UnaryInterceptors, p.unaryInterceptor())
+	})
+}
+
+// retryPolicy holds a validated, defaulted WithRetryPolicy configuration.
+type retryPolicy struct {
+	maxAttempts    int
+	baseDelay      time.Duration
+	maxDelay       time.Duration
+	retryableCodes []Code
+}
+
+// retryable reports whether err is classified as transient by p.
+func (p *retryPolicy) retryable(err error) bool {
+	re, ok := AsError(err)
+	if !ok {
+		return false
+	}
+	for _, code := range p.retryableCodes {
+		if re.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the attempt'th retry (0-indexed),
+// honoring a Retry-After header on err, or else a RetryInfo detail's retry
+// delay, if either is present.
+func (p *retryPolicy) backoff(err error, attempt int) time.Duration {
+	if re, ok := AsError(err); ok {
+		if d, ok := retryAfter(re.Header()); ok {
+			return d
+		}
+		info := new(errdetails.RetryInfo)
+		if re.Detail(info) && info.RetryDelay != nil {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	capped := math.Min(float64(p.maxDelay), float64(p.baseDelay)*math.Pow(2, float64(attempt)))
+	return time.Duration(rand.Int63n(int64(capped) + 1))
+}
+
+// wait sleeps for delay, returning early with ctx.Err() if ctx is done or
+// its deadline would elapse before delay does.
+func (p *retryPolicy) wait(ctx context.Context, delay time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		return errorf(CodeDeadlineExceeded, "retry delay exceeds context deadline")
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (p *retryPolicy) unaryInterceptor() UnaryInterceptor {
+	return func(ctx context.Context, req proto.Message, info *CallMeta, next UnaryHandlerFunc) (proto.Message, error) {
+		var lastErr error
+		for attempt := 0; attempt < p.maxAttempts; attempt++ {
+			resp, err := next(ctx, req)
+			if err == nil {
+				return resp, nil
+			}
+			lastErr = err
+			if attempt == p.maxAttempts-1 || !p.retryable(err) {
+				return nil, err
+			}
+			if err := p.wait(ctx, p.backoff(lastErr, attempt)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, lastErr
+	}
+}
+
+// retryAfter parses the Retry-After header as either a number of seconds or
+// an HTTP-date, per RFC 9110 Section 10.2.3. It returns false if h has no
+// usable Retry-After value.
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,153 @@
+package rerpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// streamType identifies which RPC shape a Handler implements. The zero
+// value, streamTypeUnary, is a plain request/response handler.
+type streamType int
+
+const (
+	streamTypeUnary streamType = iota
+	streamTypeServer
+	streamTypeClient
+	streamTypeBidi
+)
+
+// frameWriter writes a single response message using whatever framing the
+// active protocol requires (an LPM envelope for gRPC and gRPC-Web, a
+// newline-delimited JSON value for JSON).
+type frameWriter interface {
+	writeMessage(proto.Message) error
+}
+
+type lpmFrameWriter struct {
+	w           io.Writer
+	compression string
+}
+
+func (fw *lpmFrameWriter) writeMessage(msg proto.Message) error {
+	return marshalLPM(fw.w, msg, fw.compression, 0 /* maxBytes */)
+}
+
+// ndjsonFrameWriter implements server-streaming over the JSON protocol as a
+// stream of newline-delimited JSON values, since JSON has no standard
+// message-framing of its own.
+type ndjsonFrameWriter struct {
+	w io.Writer
+}
+
+func (fw *ndjsonFrameWriter) writeMessage(msg proto.Message) error {
+	if err := marshalJSON(fw.w, msg); err != nil {
+		return err
+	}
+	_, err := fw.w.Write([]byte("\n"))
+	return err
+}
+
+// A ServerStream lets a streaming handler send a sequence of response
+// messages to the client. Each call to Send is flushed immediately, so
+// clients can start processing results before the handler finishes.
+type ServerStream struct {
+	fw      frameWriter
+	flusher http.Flusher
+}
+
+func newServerStream(fw frameWriter, flusher http.Flusher) *ServerStream {
+	return &ServerStream{fw: fw, flusher: flusher}
+}
+
+// Send writes msg to the client and flushes the connection.
+func (s *ServerStream) Send(msg proto.Message) error {
+	if err := s.fw.writeMessage(msg); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// A ClientStream lets a streaming handler read a sequence of request
+// messages from the client.
+type ClientStream struct {
+	r           io.Reader
+	compression string
+	maxBytes    int
+}
+
+func newClientStream(r io.Reader, compression string, maxBytes int) *ClientStream {
+	return &ClientStream{r: r, compression: compression, maxBytes: maxBytes}
+}
+
+// Receive resets msg and unmarshals the next request message into it. Once
+// the client has sent every message, Receive returns io.EOF.
+func (s *ClientStream) Receive(msg proto.Message) error {
+	proto.Reset(msg)
+	return unmarshalLPM(s.r, msg, s.compression, s.maxBytes)
+}
+
+// NewServerStreamHandler constructs a Handler for a server-streaming RPC:
+// one request message followed by zero or more response messages. It's
+// meant to be called by generated code; see NewHandler for the unary
+// equivalent.
+func NewServerStreamHandler(
+	fqn string,
+	impl func(context.Context, proto.Message, *ServerStream) error,
+	opts ...HandlerOption,
+) *Handler {
+	return &Handler{
+		fqn:                        fqn,
+		serverStreamImplementation: impl,
+		streamType:                 streamTypeServer,
+		config:                     newHandlerCfg(fqn, opts),
+	}
+}
+
+// NewClientStreamHandler constructs a Handler for a client-streaming RPC:
+// zero or more request messages followed by one response message.
+func NewClientStreamHandler(
+	fqn string,
+	impl func(context.Context, *ClientStream) (proto.Message, error),
+	opts ...HandlerOption,
+) *Handler {
+	return &Handler{
+		fqn:                        fqn,
+		clientStreamImplementation: impl,
+		streamType:                 streamTypeClient,
+		config:                     newHandlerCfg(fqn, opts),
+	}
+}
+
+// NewBidiStreamHandler constructs a Handler for a bidirectionally-streaming
+// RPC: request and response messages interleaved in any order. Bidi
+// streaming isn't available over the JSON or gRPC-Web protocols, so these
+// handlers only serve application/grpc requests.
+func NewBidiStreamHandler(
+	fqn string,
+	impl func(context.Context, *ClientStream, *ServerStream) error,
+	opts ...HandlerOption,
+) *Handler {
+	return &Handler{
+		fqn:                      fqn,
+		bidiStreamImplementation: impl,
+		streamType:               streamTypeBidi,
+		config:                   newHandlerCfg(fqn, opts),
+	}
+}
+
+func newHandlerCfg(fqn string, opts []HandlerOption) handlerCfg {
+	var cfg handlerCfg
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if reg := cfg.Registrar; reg != nil {
+		reg.register(fqn)
+	}
+	return cfg
+}